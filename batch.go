@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runOptions bundles the per-run configuration shared across every image
+// in a batch, so processImage doesn't need a dozen positional arguments.
+type runOptions struct {
+	volatilityPath string
+	outputFormat   string
+	normalize      bool
+	moduleTimeout  time.Duration
+	force          bool
+	moduleParallel int
+	scheduler      *WeightedScheduler
+	profiles       map[string]ModuleProfile
+	manifest       *Manifest
+	lg             *Logger
+}
+
+// resolveImages expands the -i/-I flags into a concrete list of memory
+// image paths. imagesArg may name a newline-delimited text file or a
+// directory (every regular file in it is treated as an image); exactly
+// one of image/imagesArg is expected to be set, which main enforces.
+func resolveImages(image, imagesArg string) ([]string, error) {
+	if image != "" {
+		return []string{image}, nil
+	}
+
+	info, err := os.Stat(imagesArg)
+	if err != nil {
+		return nil, fmt.Errorf("reading -I %s: %w", imagesArg, err)
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(imagesArg)
+		if err != nil {
+			return nil, fmt.Errorf("reading image directory %s: %w", imagesArg, err)
+		}
+		var images []string
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				images = append(images, filepath.Join(imagesArg, entry.Name()))
+			}
+		}
+		sort.Strings(images)
+		return images, nil
+	}
+
+	data, err := os.ReadFile(imagesArg)
+	if err != nil {
+		return nil, fmt.Errorf("reading image list %s: %w", imagesArg, err)
+	}
+	var images []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			images = append(images, line)
+		}
+	}
+	return images, nil
+}
+
+// processImage runs every pending module against a single image and
+// tracks progress in the shared manifest under that image's (image,
+// module) keys. When nested is true (batch mode, -I), output goes to
+// baseOutputDir/<image-basename>/; when false (single-image mode, -i),
+// output goes directly to baseOutputDir, preserving the flat
+// outputDir/<image>_<module>.csv layout predating batch mode. Concurrency
+// is capped both by opts.moduleParallel (this image's own worker pool)
+// and by opts.scheduler, which is shared across every image in the batch.
+func processImage(ctx context.Context, opts runOptions, image, baseOutputDir string, nested bool, modules []string) error {
+	imageName := filepath.Base(image)
+	outputDir := baseOutputDir
+	if nested {
+		outputDir = filepath.Join(baseOutputDir, imageName)
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("creating output directory for image %s: %w", imageName, err)
+		}
+	}
+
+	pending := modules[:0:0]
+	for _, module := range modules {
+		if reason := opts.manifest.skipReason(imageName, module, opts.force); reason != "" {
+			opts.lg.Infof(recordKey(imageName, module), "Skipping module: %s", reason)
+			continue
+		}
+		pending = append(pending, module)
+		opts.manifest.markPending(imageName, module)
+	}
+
+	moduleSem := make(chan struct{}, opts.moduleParallel)
+	var wg sync.WaitGroup
+
+	for _, module := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case moduleSem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		profile := profileFor(opts.profiles, module)
+		if !opts.scheduler.acquire(ctx, profile.CPUWeight, profile.MemMB) {
+			<-moduleSem
+			continue
+		}
+		wg.Add(1)
+		go func(module string, profile ModuleProfile) {
+			defer func() { <-moduleSem }()
+			defer opts.scheduler.release(profile.CPUWeight, profile.MemMB)
+			runModule(ctx, opts.volatilityPath, image, module, outputDir, opts.outputFormat, opts.normalize, opts.moduleTimeout, opts.manifest, opts.lg, &wg)
+		}(module, profile)
+	}
+
+	wg.Wait()
+	return nil
+}
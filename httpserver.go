@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dashboardTemplate renders the auto-refreshing module status page served
+// at "/". It offers a way besides "press Enter" to check on a run that's
+// running on a remote box over SSH.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="3">
+<title>vol_wrapper status</title>
+<style>
+body { font-family: monospace; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ccc; }
+.status-pending { color: #757575; }
+.status-running { color: #b8860b; }
+.status-success { color: #2e7d32; }
+.status-error, .status-timeout { color: #c62828; }
+</style>
+</head>
+<body>
+<h1>vol_wrapper status</h1>
+<p>Generated {{.Now}}</p>
+<table>
+<tr><th>Image</th><th>Module</th><th>Status</th><th>Elapsed</th><th>Exit code</th><th>Output size</th></tr>
+{{range .Rows}}<tr><td>{{.Image}}</td><td>{{.Module}}</td><td class="status-{{.Status}}">{{.Status}}</td><td>{{.Elapsed}}</td><td>{{.ExitCode}}</td><td>{{.OutputSize}}</td></tr>
+{{end}}</table>
+<p><a href="/modules.json">/modules.json</a> | <a href="/log">/log</a> | <a href="/pprof/">/pprof/</a></p>
+</body>
+</html>
+`))
+
+// dashboardRow is one rendered table row; Elapsed and OutputSize are
+// pre-formatted since text/html templates shouldn't do that work inline.
+type dashboardRow struct {
+	Image      string
+	Module     string
+	Status     ModuleStatus
+	Elapsed    string
+	ExitCode   int
+	OutputSize string
+}
+
+// statusServer holds what the HTTP handlers need to read: the manifest and
+// the logger's ring buffer. Module start times are read from the
+// package-level runningModules.
+type statusServer struct {
+	manifest *Manifest
+	lg       *Logger
+}
+
+func (s *statusServer) rows() []dashboardRow {
+	snapshot := s.manifest.snapshot()
+	rows := make([]dashboardRow, 0, len(snapshot))
+	for key, rec := range snapshot {
+		elapsed := "-"
+		if start, ok := runningModules.Load(key); ok {
+			elapsed = time.Since(start.(time.Time)).Round(time.Second).String()
+		} else if rec.DurationMS > 0 {
+			elapsed = time.Duration(rec.DurationMS * int64(time.Millisecond)).String()
+		}
+
+		size := "-"
+		if info, err := os.Stat(rec.OutputFile); err == nil {
+			size = fmt.Sprintf("%d bytes", info.Size())
+		}
+
+		rows = append(rows, dashboardRow{
+			Image:      rec.Image,
+			Module:     rec.Module,
+			Status:     rec.Status,
+			Elapsed:    elapsed,
+			ExitCode:   rec.ExitCode,
+			OutputSize: size,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Image != rows[j].Image {
+			return rows[i].Image < rows[j].Image
+		}
+		return rows[i].Module < rows[j].Module
+	})
+	return rows
+}
+
+func (s *statusServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Now  string
+		Rows []dashboardRow
+	}{Now: time.Now().Format(time.RFC3339), Rows: s.rows()}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		s.lg.Errorf("", "Error rendering dashboard: %v", err)
+	}
+}
+
+func (s *statusServer) handleModulesJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.manifest.snapshot())
+}
+
+func (s *statusServer) handleLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range s.lg.tail(500) {
+		fmt.Fprintln(w, line.String())
+	}
+}
+
+// handlePprofIndex serves the profile index and named profile lookups
+// (heap, goroutine, block, ...) under the /pprof/ prefix. pprof.Index only
+// recognizes the standard library's own "/debug/pprof/" prefix, so named
+// profiles are dispatched by hand here.
+func handlePprofIndex(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/pprof/")
+	if name == "" {
+		pprof.Index(w, r)
+		return
+	}
+	pprof.Handler(name).ServeHTTP(w, r)
+}
+
+// startStatusServer starts the embedded HTTP dashboard in the background
+// and returns a function that shuts it down. Serving errors other than a
+// clean shutdown are logged, not fatal, since the dashboard is a
+// convenience and shouldn't take a run down with it.
+func startStatusServer(addr string, manifest *Manifest, lg *Logger) func(context.Context) error {
+	s := &statusServer{manifest: manifest, lg: lg}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleDashboard)
+	mux.HandleFunc("/modules.json", s.handleModulesJSON)
+	mux.HandleFunc("/log", s.handleLog)
+	mux.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/pprof/", handlePprofIndex)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			lg.Errorf("", "HTTP status server error: %v", err)
+		}
+	}()
+	lg.Infof("", "HTTP status server listening on %s", addr)
+
+	return server.Shutdown
+}
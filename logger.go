@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Verbosity levels for the logger, modeled after syzkaller's log package:
+// 0 only prints errors, 3 prints per-module
+// chatter useful for diagnosing a stalled plugin.
+const (
+	V0 = iota
+	V1
+	V2
+	V3
+)
+
+// ringBufferSize caps how many log lines are kept in memory for the
+// Enter-key summary and the SIGUSR1 dump.
+const ringBufferSize = 2000
+
+// logLine is one entry in the ring buffer.
+type logLine struct {
+	time    time.Time
+	module  string
+	level   int
+	message string
+}
+
+func (l logLine) String() string {
+	prefix := l.time.Format("15:04:05.000")
+	if l.module != "" {
+		return fmt.Sprintf("[%s] [%s] %s", prefix, l.module, l.message)
+	}
+	return fmt.Sprintf("[%s] %s", prefix, l.message)
+}
+
+// Logger is a small structured logger with a verbosity filter and an
+// in-memory ring buffer of recent lines. It replaces the ad-hoc
+// fmt.Printf calls scattered through runModule, monitorKeyPress, and main.
+type Logger struct {
+	mu        sync.Mutex
+	verbosity int
+	lines     []logLine
+	next      int
+	full      bool
+}
+
+// newLogger creates a Logger at the given verbosity level.
+func newLogger(verbosity int) *Logger {
+	return &Logger{
+		verbosity: verbosity,
+		lines:     make([]logLine, ringBufferSize),
+	}
+}
+
+// log records a line at level for module (module may be "" for global
+// messages) and prints it to stdout if verbosity allows.
+func (lg *Logger) log(level int, module, format string, args ...interface{}) {
+	line := logLine{time: time.Now(), module: module, level: level, message: fmt.Sprintf(format, args...)}
+
+	lg.mu.Lock()
+	lg.lines[lg.next] = line
+	lg.next = (lg.next + 1) % len(lg.lines)
+	if lg.next == 0 {
+		lg.full = true
+	}
+	lg.mu.Unlock()
+
+	if level <= lg.verbosity {
+		fmt.Println(line.String())
+	}
+}
+
+func (lg *Logger) Errorf(module, format string, args ...interface{}) { lg.log(V0, module, format, args...) }
+func (lg *Logger) Infof(module, format string, args ...interface{})  { lg.log(V1, module, format, args...) }
+func (lg *Logger) Debugf(module, format string, args ...interface{}) { lg.log(V2, module, format, args...) }
+func (lg *Logger) Tracef(module, format string, args ...interface{}) { lg.log(V3, module, format, args...) }
+
+// tail returns the last n cached lines in chronological order.
+func (lg *Logger) tail(n int) []logLine {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	var ordered []logLine
+	if lg.full {
+		ordered = append(ordered, lg.lines[lg.next:]...)
+	}
+	ordered = append(ordered, lg.lines[:lg.next]...)
+
+	if n > 0 && len(ordered) > n {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// dumpToFile writes the full cached log to path, used by the SIGUSR1
+// handler so users can grab diagnostics without stopping a running wrapper.
+func (lg *Logger) dumpToFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, line := range lg.tail(0) {
+		if _, err := fmt.Fprintln(f, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// watchDumpSignal is a no-op on platforms without SIGUSR1; it blocks until
+// done is closed so callers can still run it as a goroutine unconditionally.
+func watchDumpSignal(lg *Logger, dumpPath string, done <-chan struct{}) {
+	<-done
+}
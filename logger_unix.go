@@ -0,0 +1,31 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchDumpSignal dumps the full cached log to dumpPath every time SIGUSR1
+// is received, until done is closed. SIGUSR1 has no Windows equivalent, so
+// this is a no-op there (see logger_other.go).
+func watchDumpSignal(lg *Logger, dumpPath string, done <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-sigs:
+			if err := lg.dumpToFile(dumpPath); err != nil {
+				lg.Errorf("", "Error dumping log to %s: %v", dumpPath, err)
+			} else {
+				lg.Infof("", "Dumped log to %s", dumpPath)
+			}
+		case <-done:
+			return
+		}
+	}
+}
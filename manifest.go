@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ModuleStatus is the lifecycle state of a module run as recorded in the
+// manifest.
+type ModuleStatus string
+
+const (
+	StatusPending ModuleStatus = "pending"
+	StatusRunning ModuleStatus = "running"
+	StatusSuccess ModuleStatus = "success"
+	StatusError   ModuleStatus = "error"
+	StatusTimeout ModuleStatus = "timeout"
+)
+
+// ModuleRecord tracks everything the manifest needs to know about one
+// (image, module) run so that a crashed or aborted invocation can be
+// resumed. Image is always set; in single-image mode it's just the one
+// image the wrapper was pointed at.
+type ModuleRecord struct {
+	Image      string       `json:"image"`
+	Module     string       `json:"module"`
+	Status     ModuleStatus `json:"status"`
+	StartTime  time.Time    `json:"start_time,omitempty"`
+	EndTime    time.Time    `json:"end_time,omitempty"`
+	DurationMS int64        `json:"duration_ms,omitempty"`
+	ExitCode   int          `json:"exit_code"`
+	OutputFile string       `json:"output_file,omitempty"`
+	StderrTail string       `json:"stderr_tail,omitempty"`
+}
+
+// recordKey identifies a (image, module) pair as a single manifest key,
+// so the same manifest can track progress across a batch of images.
+func recordKey(image, module string) string {
+	return image + "::" + module
+}
+
+// Manifest is the JSON document persisted as run.json in the output
+// directory. It is safe for concurrent use.
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	Records map[string]*ModuleRecord `json:"records"`
+}
+
+// manifestFileName is the name of the manifest file inside the output
+// directory.
+const manifestFileName = "run.json"
+
+// loadManifest reads an existing manifest from outputDir, or returns a
+// freshly initialized one if none exists yet.
+func loadManifest(outputDir string) (*Manifest, error) {
+	path := fmt.Sprintf("%s%c%s", outputDir, os.PathSeparator, manifestFileName)
+	m := &Manifest{path: path, Records: map[string]*ModuleRecord{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// saveLocked writes the manifest to disk as indented JSON. Callers must
+// already hold m.mu.
+func (m *Manifest) saveLocked() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, data, 0644)
+}
+
+// markPending records (image, module) as pending and persists the
+// manifest, so a module still queued behind -module-parallel or the
+// WeightedScheduler's budget shows up in run.json and the HTTP dashboard
+// instead of being invisible until it's actually admitted to run.
+func (m *Manifest) markPending(image, module string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Records[recordKey(image, module)] = &ModuleRecord{
+		Image:  image,
+		Module: module,
+		Status: StatusPending,
+	}
+	m.saveLocked()
+}
+
+// start marks (image, module) as running and persists the manifest.
+// outputFile is recorded up front (before the module has produced any
+// output) so the HTTP dashboard can show its size as it grows.
+func (m *Manifest) start(image, module, outputFile string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Records[recordKey(image, module)] = &ModuleRecord{
+		Image:      image,
+		Module:     module,
+		Status:     StatusRunning,
+		StartTime:  time.Now(),
+		OutputFile: outputFile,
+	}
+	m.saveLocked()
+}
+
+// finish records the outcome of an (image, module) run and persists the
+// manifest.
+func (m *Manifest) finish(image, module string, status ModuleStatus, exitCode int, outputFile, stderrTail string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := recordKey(image, module)
+	rec, ok := m.Records[key]
+	if !ok {
+		rec = &ModuleRecord{Image: image, Module: module}
+		m.Records[key] = rec
+	}
+	rec.Status = status
+	rec.EndTime = time.Now()
+	if !rec.StartTime.IsZero() {
+		rec.DurationMS = rec.EndTime.Sub(rec.StartTime).Milliseconds()
+	}
+	rec.ExitCode = exitCode
+	rec.OutputFile = outputFile
+	rec.StderrTail = stderrTail
+	m.saveLocked()
+}
+
+// snapshot returns a copy of all records, safe to serialize or render
+// without holding the manifest's lock.
+func (m *Manifest) snapshot() map[string]ModuleRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]ModuleRecord, len(m.Records))
+	for module, rec := range m.Records {
+		out[module] = *rec
+	}
+	return out
+}
+
+// failed returns the records for modules currently in an error or timeout
+// state, for use in status summaries.
+func (m *Manifest) failed() []*ModuleRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*ModuleRecord
+	for _, rec := range m.Records {
+		if rec.Status == StatusError || rec.Status == StatusTimeout {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// skipReason returns why an (image, module) pair should be skipped on
+// this run, or "" if it should be (re)run. Pairs already marked success
+// are skipped unless force is set; anything else (pending, running,
+// error, timeout, or never recorded) is re-run.
+func (m *Manifest) skipReason(image, module string, force bool) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.Records[recordKey(image, module)]
+	if !ok || force {
+		return ""
+	}
+	if rec.Status == StatusSuccess {
+		return "already completed successfully"
+	}
+	return ""
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// normalizedSuffix marks the per-module normalized JSONL event stream
+// produced by normalizeModuleOutput, so mergeEvents can find them without
+// also picking up the raw module output files.
+const normalizedSuffix = ".events.jsonl"
+
+// mergedEventsFileName is the combined stream written by -merge.
+const mergedEventsFileName = "events.jsonl"
+
+// Event is the normalized record written to each module's *.events.jsonl
+// file and to the merged events.jsonl. Fields follow what volatility
+// plugins most commonly expose; anything else a plugin emits is kept
+// under Artifact so nothing is silently dropped.
+type Event struct {
+	Image     string            `json:"image"`
+	Module    string            `json:"module"`
+	Timestamp string            `json:"timestamp,omitempty"`
+	PID       string            `json:"pid,omitempty"`
+	Process   string            `json:"process,omitempty"`
+	Artifact  map[string]string `json:"artifact,omitempty"`
+}
+
+// pidColumns and timeColumns are the header names (case-insensitive) this
+// wrapper recognizes from volatility3's renderers; anything else ends up
+// in Artifact rather than being dropped.
+var (
+	pidColumns     = []string{"pid", "process id", "processid"}
+	processColumns = []string{"process", "imagefilename", "process name"}
+	timeColumns    = []string{"createtime", "create time", "lastwritetime", "last write time", "timestamp", "modified", "created"}
+)
+
+// normalizeModuleOutput reads a module's raw output file (csv or
+// json/jsonl, as produced by the -output-format flag) and writes a
+// normalized JSONL event stream alongside it. Returns the path written,
+// or "" if the format can't be normalized (e.g. "pretty" is free text).
+func normalizeModuleOutput(outputFile, format, image, module string) (string, error) {
+	rows, err := readRows(outputFile, format)
+	if err != nil {
+		return "", err
+	}
+	if rows == nil {
+		return "", nil
+	}
+
+	normalizedPath := strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + normalizedSuffix
+	out, err := os.Create(normalizedPath)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	for _, row := range rows {
+		event := Event{Image: image, Module: module, Artifact: map[string]string{}}
+		for key, value := range row {
+			switch {
+			case matchesColumn(key, pidColumns):
+				event.PID = value
+			case matchesColumn(key, processColumns):
+				event.Process = value
+			case matchesColumn(key, timeColumns) && event.Timestamp == "":
+				event.Timestamp = value
+			default:
+				event.Artifact[key] = value
+			}
+		}
+		if err := enc.Encode(event); err != nil {
+			return "", err
+		}
+	}
+	return normalizedPath, nil
+}
+
+func matchesColumn(header string, candidates []string) bool {
+	header = strings.ToLower(strings.TrimSpace(header))
+	for _, c := range candidates {
+		if header == c {
+			return true
+		}
+	}
+	return false
+}
+
+// readRows parses a module's raw output into a slice of column->value
+// maps, dispatching on -output-format. "pretty" is volatility3's aligned
+// text table and isn't worth round-tripping, so it returns (nil, nil).
+func readRows(path, format string) ([]map[string]string, error) {
+	switch format {
+	case "csv":
+		return readCSVRows(path)
+	case "json", "jsonl":
+		return readJSONRows(path, format)
+	default:
+		return nil, nil
+	}
+}
+
+func readCSVRows(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSONRows(path, format string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var raw []map[string]interface{}
+	if format == "jsonl" {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &obj); err != nil {
+				return nil, fmt.Errorf("parsing jsonl line: %w", err)
+			}
+			raw = append(raw, obj)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := json.NewDecoder(f).Decode(&raw); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := make([]map[string]string, 0, len(raw))
+	for _, obj := range raw {
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			row[k] = fmt.Sprintf("%v", v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// mergeEvents concatenates every module's normalized event stream in
+// outputDir into a single events.jsonl, sorted by PID then timestamp, for
+// downstream tools like timesketch, jq, or ELK.
+func mergeEvents(outputDir string) (string, int, error) {
+	var events []Event
+
+	err := filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, normalizedSuffix) {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		dec := json.NewDecoder(f)
+		for {
+			var event Event
+			if err := dec.Decode(&event); err != nil {
+				break
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].PID != events[j].PID {
+			return events[i].PID < events[j].PID
+		}
+		return events[i].Timestamp < events[j].Timestamp
+	})
+
+	mergedPath := filepath.Join(outputDir, mergedEventsFileName)
+	out, err := os.Create(mergedPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return "", 0, err
+		}
+	}
+	return mergedPath, len(events), nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// setProcessGroup is a no-op outside unix: there's no process group to
+// join, so terminateProcessGroup below falls back to killing the process
+// directly.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills the process directly, since there is no
+// process group to signal on this platform.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs cmd in its own process group so SIGTERM can be
+// delivered to the whole tree instead of just the direct child. The SIGKILL
+// that exec.Cmd sends after WaitDelay reaches only the direct child.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateProcessGroup sends SIGTERM to cmd's process group, giving it a
+// chance to shut down volatility's child processes cleanly. exec.Cmd will
+// follow up with SIGKILL itself if the process is still alive once
+// WaitDelay elapses.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ModuleProfile describes the resources a module is expected to need.
+// CPUWeight is in the same units as -max-cpu-weight (roughly "cores");
+// MemMB is a resident-memory estimate. Modules with no entry in the
+// profile file get defaultProfile.
+type ModuleProfile struct {
+	CPUWeight int `json:"cpu_weight"`
+	MemMB     int `json:"mem_mb"`
+}
+
+// defaultProfile is assigned to any module absent from the profile file,
+// matching the previous one-core-per-module assumption.
+var defaultProfile = ModuleProfile{CPUWeight: 1, MemMB: 512}
+
+// loadProfiles reads a JSON file mapping module name to ModuleProfile. An
+// empty path yields no overrides, so every module falls back to
+// defaultProfile.
+func loadProfiles(path string) (map[string]ModuleProfile, error) {
+	profiles := map[string]ModuleProfile{}
+	if path == "" {
+		return profiles, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading module profile file: %w", err)
+	}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("parsing module profile file %s (expected JSON object of module -> {cpu_weight, mem_mb}): %w", path, err)
+	}
+	return profiles, nil
+}
+
+func profileFor(profiles map[string]ModuleProfile, module string) ModuleProfile {
+	if p, ok := profiles[module]; ok {
+		return p
+	}
+	return defaultProfile
+}
+
+// WeightedScheduler is a two-dimensional semaphore: a module is admitted
+// only once both a CPU weight budget and a memory budget have room for
+// it. This lets heavy modules (yarascan, timeliner) run serially while
+// light ones (pslist) pack in alongside each other, instead of the flat
+// NumCPU()-sized worker pool admitting everything equally.
+type WeightedScheduler struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	cpuBudget int
+	memBudget int
+	cpuUsed   int
+	memUsed   int
+}
+
+// newWeightedScheduler creates a scheduler with the given total CPU
+// weight and memory (MB) budgets.
+func newWeightedScheduler(cpuBudget, memBudgetMB int) *WeightedScheduler {
+	s := &WeightedScheduler{cpuBudget: cpuBudget, memBudget: memBudgetMB}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// acquire blocks until cpuWeight and memMB units are both available, then
+// reserves them. A profile that exceeds the total budget on its own is
+// clamped to the full budget so it can still run (serially). If ctx is
+// cancelled while waiting, acquire gives up and returns false without
+// reserving anything; callers must not call release in that case.
+func (s *WeightedScheduler) acquire(ctx context.Context, cpuWeight, memMB int) bool {
+	if cpuWeight > s.cpuBudget {
+		cpuWeight = s.cpuBudget
+	}
+	if memMB > s.memBudget {
+		memMB = s.memBudget
+	}
+
+	// sync.Cond has no notion of a context, so a watcher goroutine
+	// broadcasts on cancellation to wake any waiters blocked in
+	// cond.Wait() below, which then re-check ctx.Err() themselves.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Holding mu keeps the broadcast from landing between a
+			// waiter's ctx.Err() check and its cond.Wait().
+			s.mu.Lock()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for (s.cpuUsed+cpuWeight > s.cpuBudget || s.memUsed+memMB > s.memBudget) && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	s.cpuUsed += cpuWeight
+	s.memUsed += memMB
+	return true
+}
+
+// release returns cpuWeight and memMB units to the budget and wakes any
+// waiters. The values passed must match the (possibly clamped) values
+// used in the corresponding acquire.
+func (s *WeightedScheduler) release(cpuWeight, memMB int) {
+	if cpuWeight > s.cpuBudget {
+		cpuWeight = s.cpuBudget
+	}
+	if memMB > s.memBudget {
+		memMB = s.memBudget
+	}
+
+	s.mu.Lock()
+	s.cpuUsed -= cpuWeight
+	s.memUsed -= memMB
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// availableMemMB reads MemAvailable from /proc/meminfo and returns a
+// fraction of it as the default memory budget. Falls back to 1024MB if
+// /proc/meminfo isn't readable (e.g. non-Linux) or has no usable
+// MemAvailable line.
+func availableMemMB(fraction float64) int {
+	const fallbackMB = 1024
+
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return fallbackMB
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "MemAvailable:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		kb, err := strconv.Atoi(fields[1])
+		if err != nil {
+			break
+		}
+		return int(float64(kb) / 1024 * fraction)
+	}
+	return fallbackMB
+}
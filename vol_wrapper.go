@@ -2,63 +2,160 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
+// stderrTailLimit bounds how much stderr is kept for the manifest so a
+// chatty plugin can't blow up run.json.
+const stderrTailLimit = 4096
+
 var (
 	runningModules sync.Map
 )
 
-func runModule(volatilityPath, memoryImage, module, outputDir string, wg *sync.WaitGroup) {
+// gracePeriod is how long a module is given to exit after SIGTERM before
+// SIGKILL is sent.
+const gracePeriod = 5 * time.Second
+
+func runModule(ctx context.Context, volatilityPath, memoryImage, module, outputDir, outputFormat string, normalize bool, timeout time.Duration, manifest *Manifest, lg *Logger, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	memoryImageName := memoryImage[strings.LastIndex(memoryImage, string(os.PathSeparator))+1:]
+	runKey := recordKey(memoryImageName, module)
+
 	start := time.Now()
-	runningModules.Store(module, start)
-	defer runningModules.Delete(module)
+	runningModules.Store(runKey, start)
+	defer runningModules.Delete(runKey)
 
-	memoryImageName := memoryImage[strings.LastIndex(memoryImage, string(os.PathSeparator))+1:]
-	outputFile := fmt.Sprintf("%s%c%s_%s.csv", outputDir, os.PathSeparator, memoryImageName, module)
+	outputFile := fmt.Sprintf("%s%c%s_%s.%s", outputDir, os.PathSeparator, memoryImageName, module, outputFileExt(outputFormat))
+	manifest.start(memoryImageName, module, outputFile)
+
+	moduleCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		moduleCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	args := []string{"-f", memoryImage}
+	if outputFormat != "pretty" {
+		args = append(args, "-r", outputFormat)
+	}
+	args = append(args, module)
+
+	cmd := exec.CommandContext(moduleCtx, volatilityPath, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminateProcessGroup(cmd)
+	}
+	cmd.WaitDelay = gracePeriod
 
-	cmd := exec.Command(volatilityPath, "-f", memoryImage, "-r", "csv", module)
 	outfile, err := os.Create(outputFile)
 	if err != nil {
-		fmt.Printf("Error creating output file for module %s: %v\n", module, err)
+		lg.Errorf(runKey, "Error creating output file: %v", err)
+		manifest.finish(memoryImageName, module, StatusError, -1, outputFile, err.Error())
 		return
 	}
 	defer outfile.Close()
 
+	var stderrTail bytes.Buffer
 	cmd.Stdout = outfile
-	cmd.Stderr = nil // Suppress progress output
+	cmd.Stderr = &stderrTail // Keep a bounded tail for the manifest and log ring buffer
+
+	lg.Infof(runKey, "Running module")
+	err = cmd.Run()
 
-	fmt.Printf("Running module: %s\n", module)
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("!--- Error running module %s: %v\n", module, err)
-	} else {
+	status := StatusSuccess
+	exitCode := 0
+	switch {
+	case moduleCtx.Err() == context.DeadlineExceeded:
+		status, exitCode = StatusTimeout, -1
+		lg.Errorf(runKey, "Module timed out after %s", timeout)
+	case ctx.Err() != nil:
+		status, exitCode = StatusError, -1
+		lg.Errorf(runKey, "Module cancelled")
+	case err != nil:
+		status = StatusError
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+		}
+		lg.Errorf(runKey, "Error running module: %v", err)
+	default:
 		duration := time.Since(start).Seconds()
-		fmt.Printf("    Module %s completed in %.2f seconds\n", module, duration)
+		lg.Infof(runKey, "Module completed in %.2f seconds", duration)
+		if normalize {
+			if normalizedPath, err := normalizeModuleOutput(outputFile, outputFormat, memoryImageName, module); err != nil {
+				lg.Errorf(runKey, "Error normalizing output: %v", err)
+			} else if normalizedPath != "" {
+				lg.Debugf(runKey, "Normalized output written to %s", normalizedPath)
+			}
+		}
+	}
+	if tailText := tail(stderrTail.String(), stderrTailLimit); tailText != "" {
+		lg.Debugf(runKey, "stderr: %s", strings.TrimSpace(tailText))
+	}
+
+	manifest.finish(memoryImageName, module, status, exitCode, outputFile, tail(stderrTail.String(), stderrTailLimit))
+}
+
+// outputFileExt maps an -output-format value to the extension used for a
+// module's raw output file.
+func outputFileExt(format string) string {
+	switch format {
+	case "json", "jsonl":
+		return format
+	case "pretty":
+		return "txt"
+	default:
+		return "csv"
 	}
 }
 
-func monitorKeyPress() {
+// tail returns at most limit bytes from the end of s.
+func tail(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[len(s)-limit:]
+}
+
+// monitorKeyPress waits for Enter and prints a status snapshot: currently
+// running modules, the tail of the cached log, and the last error seen
+// from each failed module.
+func monitorKeyPress(lg *Logger, manifest *Manifest) {
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		_, err := reader.ReadByte() // Wait for a key press
 		if err == nil {
 			fmt.Println("\n->->->->->->->->->-> Currently running modules <-<-<-<-<-<-<-<-<-<-")
 			runningModules.Range(func(key, value interface{}) bool {
-				module := key.(string)
+				runKey := key.(string)
 				start := value.(time.Time)
 				runtime := time.Since(start).Seconds()
-				fmt.Printf("Module: %s, Runtime: %.2f seconds\n", module, runtime)
+				fmt.Printf("%s, Runtime: %.2f seconds\n", runKey, runtime)
 				return true
 			})
+			fmt.Println("->->->->->->->->->->-> Recent log output <-<-<-<-<-<-<-<-<-<-")
+			for _, line := range lg.tail(20) {
+				fmt.Println(line.String())
+			}
+			fmt.Println("->->->->->->->->->->-> Last error per module <-<-<-<-<-<-<-<-<-<-")
+			for _, rec := range manifest.failed() {
+				fmt.Printf("Image: %s, Module: %s, exit code: %d, stderr: %s\n", rec.Image, rec.Module, rec.ExitCode, strings.TrimSpace(rec.StderrTail))
+			}
 			fmt.Println("->->->->->->->->->->->->->->-> End <-<-<-<-<-<-<-<-<-<-<-<-<-<-<-\n")
 		}
 	}
@@ -67,10 +164,23 @@ func monitorKeyPress() {
 func main() {
 	// Define flags
 	volatilityPath := flag.String("p", "", "Path to the Volatility3 executable")
-	memoryImage := flag.String("i", "", "Path to the memory image")
+	memoryImage := flag.String("i", "", "Path to a single memory image (mutually exclusive with -I)")
+	imagesArg := flag.String("I", "", "Path to a newline-delimited file of image paths, or a directory of images, for batch mode (mutually exclusive with -i)")
+	imageParallel := flag.Int("image-parallel", 1, "Number of images to process concurrently in batch mode")
+	moduleParallel := flag.Int("module-parallel", runtime.NumCPU(), "Max modules running concurrently per image")
 	modulesFile := flag.String("m", "", "Path to file containing list of modules (newline delimited)")
 	outputDir := flag.String("o", "", "Path to the output directory")
-
+	moduleTimeout := flag.Duration("timeout", 0, "Per-module wall-clock limit (e.g. 30m), 0 disables")
+	globalTimeout := flag.Duration("global-timeout", 0, "Wall-clock limit for the entire run (e.g. 4h), 0 disables")
+	force := flag.Bool("force", false, "Re-run all modules even if run.json marks them successful")
+	verbosity := flag.Int("v", V1, "Verbosity level (0=errors only, 1=info, 2=debug, 3=trace)")
+	profilePath := flag.String("profile", "", "Path to a JSON file mapping module name to {cpu_weight, mem_mb}; unlisted modules default to 1 CPU weight / 512MB")
+	maxCPUWeight := flag.Int("max-cpu-weight", runtime.NumCPU(), "Total CPU weight budget for concurrently running modules")
+	maxMemMB := flag.Int("max-mem-mb", 0, "Total memory budget in MB for concurrently running modules (default: 75% of /proc/meminfo MemAvailable)")
+	outputFormat := flag.String("output-format", "csv", "Volatility renderer to use: csv, json, jsonl, or pretty")
+	normalize := flag.Bool("normalize", false, "After each module finishes, write a normalized *.events.jsonl event stream alongside its raw output")
+	merge := flag.Bool("merge", false, "After all modules finish, concatenate normalized event streams into a single events.jsonl (implies -normalize)")
+	httpAddr := flag.String("http", "", "Address (e.g. :8080) to serve a live status dashboard on; disabled if empty")
 
 	// Override the default usage function
 	flag.Usage = func() {
@@ -85,29 +195,57 @@ func main() {
 				fmt.Fprintln(os.Stderr, `
 		Additional Information:
 		  - Enter/Return/↵ during execution will print currently running modules
+		  - Ctrl+C cancels outstanding modules and waits for them to exit; a second
+		    Ctrl+C exits the wrapper immediately without waiting, and does not
+		    send any further signal to modules that are still running
+		  - A run.json manifest is kept in the output directory; re-running the
+		    same command resumes by skipping modules already marked successful
+		    (pass -force to re-run everything)
+		  - SIGUSR1 dumps the full cached log to <output-dir>/wrapper.log
+		  - -normalize writes a per-module *.events.jsonl stream; -merge combines
+		    them all into <output-dir>/events.jsonl for tools like jq or timesketch
+		  - -http :addr serves a live status dashboard, handy when running over SSH
+		  - -I images.txt (or -I a-directory/) processes many images in one run
+		    instead of -i; output goes to <output-dir>/<image-basename>/, and
+		    -image-parallel/-module-parallel bound how many images and modules
+		    per image run at once (all images still share one CPU/RAM budget)
 		  - Example usage:
-		      $ go run vol_wrapper.go -p /path/to/which/vol -i /path/to/image.dd -m /path/to/modules.txt -o /path/to/output/folder/
+		      $ go run . -p /path/to/which/vol -i /path/to/image.dd -m /path/to/modules.txt -o /path/to/output/folder/
 		  - Developed under Linux, may or may not work in Windows
 		`)
 			}
 
 	flag.Parse()
 
-	if *volatilityPath == "" || *memoryImage == "" || *modulesFile == "" || *outputDir == "" {
-		fmt.Println("All flags (-p, -i, -m, -o) are required.")
+	if *volatilityPath == "" || *modulesFile == "" || *outputDir == "" {
+		fmt.Println("Flags -p, -m, and -o are required.")
+		os.Exit(1)
+	}
+	if (*memoryImage == "") == (*imagesArg == "") {
+		fmt.Println("Exactly one of -i or -I is required.")
 		os.Exit(1)
 	}
+	if *imageParallel < 1 || *moduleParallel < 1 {
+		fmt.Println("-image-parallel and -module-parallel must be at least 1.")
+		os.Exit(1)
+	}
+
+	if *merge {
+		*normalize = true
+	}
+
+	lg := newLogger(*verbosity)
 
 	// Ensure the output directory exists
 	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		fmt.Printf("Error creating output directory: %v\n", err)
+		lg.Errorf("", "Error creating output directory: %v", err)
 		os.Exit(1)
 	}
 
 	// Read modules from the file
 	file, err := os.Open(*modulesFile)
 	if err != nil {
-		fmt.Printf("Error reading modules file: %v\n", err)
+		lg.Errorf("", "Error reading modules file: %v", err)
 		os.Exit(1)
 	}
 	defer file.Close()
@@ -122,40 +260,130 @@ func main() {
 	}
 
 	if err := scanner.Err(); err != nil {
-		fmt.Printf("Error scanning modules file: %v\n", err)
+		lg.Errorf("", "Error scanning modules file: %v", err)
+		os.Exit(1)
+	}
+
+	manifest, err := loadManifest(*outputDir)
+	if err != nil {
+		lg.Errorf("", "Error loading manifest: %v", err)
+		os.Exit(1)
+	}
+
+	images, err := resolveImages(*memoryImage, *imagesArg)
+	if err != nil {
+		lg.Errorf("", "Error resolving images: %v", err)
+		os.Exit(1)
+	}
+	if len(images) == 0 {
+		lg.Errorf("", "No images found")
+		os.Exit(1)
+	}
+
+	profiles, err := loadProfiles(*profilePath)
+	if err != nil {
+		lg.Errorf("", "Error loading module profiles: %v", err)
 		os.Exit(1)
 	}
 
-	// Get the number of logical processors and limit the number of goroutines
-	numGoroutines := runtime.NumCPU() - 1
-	if numGoroutines < 1 {
-		numGoroutines = 1
+	memBudget := *maxMemMB
+	if memBudget <= 0 {
+		memBudget = availableMemMB(0.75)
 	}
+	scheduler := newWeightedScheduler(*maxCPUWeight, memBudget)
 
-	fmt.Printf("Using up to %d goroutines\n", numGoroutines)
+	lg.Infof("", "Scheduling with a budget of %d CPU weight units and %d MB", *maxCPUWeight, memBudget)
 
 	// Track total time
 	totalStart := time.Now()
 
+	// Build the run's root context: cancelled by Ctrl+C, or by -global-timeout
+	// if one was given. A second Ctrl+C forces an immediate exit instead of
+	// waiting for in-flight modules to wind down.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *globalTimeout > 0 {
+		var globalCancel context.CancelFunc
+		ctx, globalCancel = context.WithTimeout(ctx, *globalTimeout)
+		defer globalCancel()
+	}
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		lg.Infof("", "Received interrupt, cancelling outstanding modules (press Ctrl+C again to exit immediately)...")
+		cancel()
+		<-sigs
+		lg.Infof("", "Second interrupt received, exiting immediately.")
+		os.Exit(1)
+	}()
+
 	// Start key press monitoring in a separate goroutine
-	go monitorKeyPress()
+	go monitorKeyPress(lg, manifest)
+
+	// Dump the full cached log to <output-dir>/wrapper.log on SIGUSR1
+	done := make(chan struct{})
+	defer close(done)
+	dumpPath := fmt.Sprintf("%s%cwrapper.log", *outputDir, os.PathSeparator)
+	go watchDumpSignal(lg, dumpPath, done)
 
-	// Create a channel to limit concurrency
-	sem := make(chan struct{}, numGoroutines)
-	var wg sync.WaitGroup
+	if *httpAddr != "" {
+		shutdown := startStatusServer(*httpAddr, manifest, lg)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdown(shutdownCtx)
+		}()
+	}
 
-	// Run each module in a goroutine
-	for _, module := range modules {
-		sem <- struct{}{} // Acquire a spot in the semaphore
-		wg.Add(1)
-		go func(module string) {
-			runModule(*volatilityPath, *memoryImage, module, *outputDir, &wg)
-			<-sem // Release the spot in the semaphore
-		}(module)
+	opts := runOptions{
+		volatilityPath: *volatilityPath,
+		outputFormat:   *outputFormat,
+		normalize:      *normalize,
+		moduleTimeout:  *moduleTimeout,
+		force:          *force,
+		moduleParallel: *moduleParallel,
+		scheduler:      scheduler,
+		profiles:       profiles,
+		manifest:       manifest,
+		lg:             lg,
 	}
 
-	wg.Wait() // Wait for all goroutines to complete
+	// Process each image in its own goroutine, bounded by -image-parallel;
+	// within an image, module admission is bounded by -module-parallel and
+	// by the CPU/RAM scheduler, which is shared across every image.
+	imageSem := make(chan struct{}, *imageParallel)
+	var imageWG sync.WaitGroup
+	for _, image := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		imageSem <- struct{}{}
+		imageWG.Add(1)
+		go func(image string) {
+			defer imageWG.Done()
+			defer func() { <-imageSem }()
+			if err := processImage(ctx, opts, image, *outputDir, *imagesArg != "", modules); err != nil {
+				lg.Errorf("", "Error processing image %s: %v", image, err)
+			}
+		}(image)
+	}
+
+	imageWG.Wait()
 	totalDuration := time.Since(totalStart).Seconds()
-	fmt.Printf("All modules completed in %.2f seconds.\n", totalDuration)
-}
+	if ctx.Err() != nil {
+		lg.Errorf("", "Run cancelled after %.2f seconds.", totalDuration)
+		os.Exit(1)
+	}
+	lg.Infof("", "All modules completed in %.2f seconds.", totalDuration)
 
+	if *merge {
+		mergedPath, count, err := mergeEvents(*outputDir)
+		if err != nil {
+			lg.Errorf("", "Error merging event streams: %v", err)
+			os.Exit(1)
+		}
+		lg.Infof("", "Merged %d events into %s", count, mergedPath)
+	}
+}